@@ -0,0 +1,165 @@
+// Copyright 2021 ByteDance Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncx
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	counterLoads = iota
+	counterMisses
+	counterStores
+)
+
+// AtomicMap is a copy-on-write map designed for read-mostly data such as configs and feature flags.
+// Load never takes a lock, while every write copies the current map and swaps in the new one,
+// so writes cost O(n) and should be rare.
+//
+// The zero AtomicMap is empty and ready for use. An AtomicMap must not be copied after first use.
+type AtomicMap struct {
+	// counters holds the loads, misses and stores counters. They are accessed with 64-bit atomic
+	// operations, which need 8-byte alignment on 32-bit platforms. That is not guaranteed when
+	// AtomicMap is embedded in another struct, so one spare word is reserved and the counters
+	// start at whichever of the first two words is 8-byte aligned. See counter.
+	// It must stay first in the struct.
+	counters [7]uint32
+
+	mu sync.Mutex   // serializes writers
+	v  atomic.Value // holds an immutable map[interface{}]interface{}
+
+	// EnableStats turns on counting of Load calls and misses. It is off by default because
+	// every reader would write to the same counters, which costs Load its lock-free scalability.
+	// It may not be changed concurrently with calls to Load.
+	EnableStats bool
+}
+
+// AtomicMapStats holds the metrics of an AtomicMap.
+// Stores and Len are read together and always describe the same snapshot,
+// while Loads and Misses are read independently and may race with concurrent Load calls.
+type AtomicMapStats struct {
+	Loads  uint64 // number of Load calls, only counted when EnableStats is set
+	Misses uint64 // number of Load calls that did not find the key, only counted when EnableStats is set
+	Stores uint64 // number of snapshots swapped in by writers
+	Len    int    // number of keys in the current snapshot
+}
+
+// counter returns the 8-byte aligned address of the i-th counter.
+func (m *AtomicMap) counter(i int) *uint64 {
+	if uintptr(unsafe.Pointer(&m.counters))%8 == 0 {
+		return (*uint64)(unsafe.Pointer(&m.counters[i*2]))
+	}
+	return (*uint64)(unsafe.Pointer(&m.counters[i*2+1]))
+}
+
+func (m *AtomicMap) snapshot() map[interface{}]interface{} {
+	s, _ := m.v.Load().(map[interface{}]interface{})
+	return s
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is present.
+// The ok result indicates whether value was found in the map.
+func (m *AtomicMap) Load(key interface{}) (value interface{}, ok bool) {
+	value, ok = m.snapshot()[key]
+	if m.EnableStats {
+		atomic.AddUint64(m.counter(counterLoads), 1)
+		if !ok {
+			atomic.AddUint64(m.counter(counterMisses), 1)
+		}
+	}
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (m *AtomicMap) Store(key, value interface{}) {
+	m.mu.Lock()
+	old := m.snapshot()
+	s := make(map[interface{}]interface{}, len(old)+1)
+	for k, v := range old {
+		s[k] = v
+	}
+	s[key] = value
+	m.swap(s)
+	m.mu.Unlock()
+}
+
+// StoreAll replaces the whole content of the map with the given entries.
+// The map is copied, so the caller may keep modifying entries afterwards.
+func (m *AtomicMap) StoreAll(entries map[interface{}]interface{}) {
+	s := make(map[interface{}]interface{}, len(entries))
+	for k, v := range entries {
+		s[k] = v
+	}
+	m.mu.Lock()
+	m.swap(s)
+	m.mu.Unlock()
+}
+
+// Delete deletes the value for a key.
+func (m *AtomicMap) Delete(key interface{}) {
+	m.mu.Lock()
+	old := m.snapshot()
+	if _, ok := old[key]; !ok {
+		m.mu.Unlock()
+		return
+	}
+	s := make(map[interface{}]interface{}, len(old))
+	for k, v := range old {
+		if k != key {
+			s[k] = v
+		}
+	}
+	m.swap(s)
+	m.mu.Unlock()
+}
+
+// swap must be called with m.mu held.
+func (m *AtomicMap) swap(s map[interface{}]interface{}) {
+	m.v.Store(s)
+	atomic.AddUint64(m.counter(counterStores), 1)
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// Range iterates over the snapshot taken when it is called, so it always sees a consistent view
+// and is not affected by concurrent writes.
+func (m *AtomicMap) Range(f func(key, value interface{}) bool) {
+	for k, v := range m.snapshot() {
+		if !f(k, v) {
+			break
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (m *AtomicMap) Len() int {
+	return len(m.snapshot())
+}
+
+// Stats returns the metrics of the map.
+func (m *AtomicMap) Stats() AtomicMapStats {
+	st := AtomicMapStats{
+		Loads:  atomic.LoadUint64(m.counter(counterLoads)),
+		Misses: atomic.LoadUint64(m.counter(counterMisses)),
+	}
+	m.mu.Lock()
+	st.Stores = atomic.LoadUint64(m.counter(counterStores))
+	st.Len = m.Len()
+	m.mu.Unlock()
+	return st
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 ByteDance Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncx
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestAtomicMap(t *testing.T) {
+	m := AtomicMap{EnableStats: true}
+	if _, ok := m.Load("a"); ok {
+		t.Fatal("zero AtomicMap should be empty")
+	}
+
+	m.Store("a", 1)
+	m.Store("b", 2)
+	if v, ok := m.Load("a"); !ok || v.(int) != 1 {
+		t.Fatalf("Load(a) = %v, %v", v, ok)
+	}
+	if m.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", m.Len())
+	}
+
+	m.Delete("a")
+	m.Delete("not-exist")
+	if _, ok := m.Load("a"); ok {
+		t.Fatal("a should be deleted")
+	}
+
+	entries := map[interface{}]interface{}{"x": 1, "y": 2, "z": 3}
+	m.StoreAll(entries)
+	entries["w"] = 4
+	if m.Len() != 3 {
+		t.Fatalf("StoreAll should copy its argument, Len() = %d", m.Len())
+	}
+
+	var n int
+	m.Range(func(key, value interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("Range should stop when f returns false, called %d times", n)
+	}
+
+	st := m.Stats()
+	if st.Loads != 3 || st.Misses != 2 || st.Stores != 4 || st.Len != 3 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestAtomicMapRange(t *testing.T) {
+	var m AtomicMap
+	want := map[interface{}]interface{}{"x": 1, "y": 2, "z": 3}
+	m.StoreAll(want)
+
+	got := make(map[interface{}]interface{})
+	m.Range(func(key, value interface{}) bool {
+		got[key] = value
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Range visited %v, want %v", got, want)
+	}
+
+	// Writes issued during Range must not affect the snapshot being iterated.
+	got = make(map[interface{}]interface{})
+	m.Range(func(key, value interface{}) bool {
+		m.Store("w", 4)
+		m.Delete("x")
+		m.Delete("y")
+		got[key] = value
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Range visited %v with concurrent writes, want %v", got, want)
+	}
+
+	after := map[interface{}]interface{}{"z": 3, "w": 4}
+	got = make(map[interface{}]interface{})
+	m.Range(func(key, value interface{}) bool {
+		got[key] = value
+		return true
+	})
+	if !reflect.DeepEqual(got, after) {
+		t.Fatalf("Range visited %v after writes, want %v", got, after)
+	}
+}
+
+// TestAtomicMapEmbedded guards the alignment of the counters, it panics on
+// 32-bit platforms if they are not 8-byte aligned.
+func TestAtomicMapEmbedded(t *testing.T) {
+	var s struct {
+		b int32
+		m AtomicMap
+	}
+	s.m.Store("a", 1)
+	s.m.Load("a")
+	s.m.Load("b")
+	if st := s.m.Stats(); st.Loads != 0 || st.Misses != 0 || st.Stores != 1 {
+		t.Fatalf("Load should not be counted without EnableStats: %+v", st)
+	}
+
+	s.m.EnableStats = true
+	s.m.Load("a")
+	s.m.Load("b")
+	if st := s.m.Stats(); st.Loads != 2 || st.Misses != 1 || st.Stores != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestRaceAtomicMap(t *testing.T) {
+	var m AtomicMap
+	parallel := 8
+	var wg sync.WaitGroup
+	wg.Add(parallel * 2)
+	for i := 0; i < parallel; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Store(i, j)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Load(i)
+				m.Range(func(key, value interface{}) bool { return true })
+			}
+		}(i)
+	}
+	wg.Wait()
+	if m.Len() != parallel {
+		t.Fatalf("Len() = %d, want %d", m.Len(), parallel)
+	}
+}
+
+func BenchmarkAtomicMapLoad(b *testing.B) {
+	b.Run("stats-off", func(b *testing.B) {
+		benchmarkAtomicMapLoad(b, false)
+	})
+	b.Run("stats-on", func(b *testing.B) {
+		benchmarkAtomicMapLoad(b, true)
+	})
+}
+
+func benchmarkAtomicMapLoad(b *testing.B, enableStats bool) {
+	m := AtomicMap{EnableStats: enableStats}
+	for i := 0; i < 64; i++ {
+		m.Store(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Load(i & 63)
+			i++
+		}
+	})
+}